@@ -0,0 +1,245 @@
+/*
+Copyright (C) 2017  RawSec SARL (0xrawsec)
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package rules
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+)
+
+// Predicate is anything that can be evaluated against an event
+type Predicate interface {
+	Eval(e *evtx.GoEvtxMap) bool
+}
+
+// predicateDef is the raw (YAML/JSON) description of a predicate, used to
+// build the actual Predicate tree
+type predicateDef struct {
+	Field string      `yaml:"field" json:"field"`
+	Op    string      `yaml:"op" json:"op"`
+	Value interface{} `yaml:"value" json:"value"`
+
+	And []predicateDef `yaml:"and" json:"and"`
+	Or  []predicateDef `yaml:"or" json:"or"`
+	Not *predicateDef  `yaml:"not" json:"not"`
+}
+
+// build compiles a predicateDef into a Predicate, recursing into boolean
+// combinators
+func (d predicateDef) build() (Predicate, error) {
+	switch {
+	case len(d.And) > 0:
+		preds, err := buildAll(d.And)
+		if err != nil {
+			return nil, err
+		}
+		return andPredicate(preds), nil
+	case len(d.Or) > 0:
+		preds, err := buildAll(d.Or)
+		if err != nil {
+			return nil, err
+		}
+		return orPredicate(preds), nil
+	case d.Not != nil:
+		p, err := d.Not.build()
+		if err != nil {
+			return nil, err
+		}
+		return notPredicate{p}, nil
+	default:
+		return newLeafPredicate(d.Field, d.Op, d.Value)
+	}
+}
+
+func buildAll(defs []predicateDef) ([]Predicate, error) {
+	preds := make([]Predicate, 0, len(defs))
+	for _, d := range defs {
+		p, err := d.build()
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, p)
+	}
+	return preds, nil
+}
+
+// andPredicate matches if all sub predicates match
+type andPredicate []Predicate
+
+func (p andPredicate) Eval(e *evtx.GoEvtxMap) bool {
+	for _, sub := range p {
+		if !sub.Eval(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// orPredicate matches if any sub predicate matches
+type orPredicate []Predicate
+
+func (p orPredicate) Eval(e *evtx.GoEvtxMap) bool {
+	for _, sub := range p {
+		if sub.Eval(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// notPredicate negates a single sub predicate
+type notPredicate struct {
+	sub Predicate
+}
+
+func (p notPredicate) Eval(e *evtx.GoEvtxMap) bool {
+	return !p.sub.Eval(e)
+}
+
+// leafPredicate matches a single field against a value using op
+type leafPredicate struct {
+	field string
+	op    string
+	value interface{}
+	re    *regexp.Regexp
+	cidr  *net.IPNet
+}
+
+func newLeafPredicate(field, op string, value interface{}) (*leafPredicate, error) {
+	if field == "" {
+		return nil, fmt.Errorf("predicate is missing a field")
+	}
+	lp := &leafPredicate{field: field, op: strings.ToLower(op), value: value}
+	switch lp.op {
+	case "regex":
+		re, err := regexp.Compile(fmt.Sprintf("%v", value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %v: %s", value, err)
+		}
+		lp.re = re
+	case "cidr":
+		_, ipnet, err := net.ParseCIDR(fmt.Sprintf("%v", value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %v: %s", value, err)
+		}
+		lp.cidr = ipnet
+	}
+	return lp, nil
+}
+
+// Eval resolves the field on the event and applies the operator
+func (p *leafPredicate) Eval(e *evtx.GoEvtxMap) bool {
+	got, ok := getField(e, p.field)
+	if !ok {
+		return false
+	}
+
+	switch p.op {
+	case "", "eq", "equals":
+		return fmt.Sprintf("%v", got) == fmt.Sprintf("%v", p.value)
+	case "neq":
+		return fmt.Sprintf("%v", got) != fmt.Sprintf("%v", p.value)
+	case "regex":
+		return p.re.MatchString(fmt.Sprintf("%v", got))
+	case "wildcard":
+		match, _ := path.Match(fmt.Sprintf("%v", p.value), fmt.Sprintf("%v", got))
+		return match
+	case "cidr":
+		ip := net.ParseIP(fmt.Sprintf("%v", got))
+		return ip != nil && p.cidr.Contains(ip)
+	case "gt", "gte", "lt", "lte":
+		return compareNumeric(got, p.value, p.op)
+	default:
+		return false
+	}
+}
+
+// compareNumeric coerces both operands to float64 and applies op
+func compareNumeric(got, want interface{}, op string) bool {
+	a, aok := toFloat(got)
+	b, bok := toFloat(want)
+	if !aok || !bok {
+		return false
+	}
+	switch op {
+	case "gt":
+		return a > b
+	case "gte":
+		return a >= b
+	case "lt":
+		return a < b
+	case "lte":
+		return a <= b
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// getField resolves a dot separated path (e.g. "EventData.Image") against
+// a GoEvtxMap, descending into nested maps as needed
+func getField(e *evtx.GoEvtxMap, field string) (interface{}, bool) {
+	var cur interface{} = map[string]interface{}(*e)
+	for _, part := range strings.Split(field, ".") {
+		m, ok := asMap(cur)
+		if !ok {
+			return nil, false
+		}
+		cur, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// asMap normalizes the two map shapes used throughout GoEvtxMap values
+func asMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case evtx.GoEvtxMap:
+		return map[string]interface{}(m), true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,226 @@
+/*
+Copyright (C) 2017  RawSec SARL (0xrawsec)
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+)
+
+func mapEvent(data map[string]interface{}) *evtx.GoEvtxMap {
+	m := evtx.GoEvtxMap(data)
+	return &m
+}
+
+func TestLeafEquals(t *testing.T) {
+	e := mapEvent(map[string]interface{}{"EventID": float64(4688)})
+
+	p, err := newLeafPredicate("EventID", "eq", float64(4688))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !p.Eval(e) {
+		t.Fatal("expected predicate to match")
+	}
+
+	p, err = newLeafPredicate("EventID", "eq", float64(4689))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.Eval(e) {
+		t.Fatal("expected predicate not to match")
+	}
+}
+
+func TestLeafNotEquals(t *testing.T) {
+	e := mapEvent(map[string]interface{}{"User": "SYSTEM"})
+
+	p, err := newLeafPredicate("User", "neq", "SYSTEM")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.Eval(e) {
+		t.Fatal("expected predicate not to match")
+	}
+}
+
+func TestLeafRegex(t *testing.T) {
+	e := mapEvent(map[string]interface{}{"Image": `C:\Windows\System32\cmd.exe`})
+
+	p, err := newLeafPredicate("Image", "regex", `(?i)\\cmd\.exe$`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !p.Eval(e) {
+		t.Fatal("expected regex predicate to match")
+	}
+}
+
+func TestLeafWildcard(t *testing.T) {
+	e := mapEvent(map[string]interface{}{"Image": `cmd.exe`})
+
+	p, err := newLeafPredicate("Image", "wildcard", "*.exe")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !p.Eval(e) {
+		t.Fatal("expected wildcard predicate to match")
+	}
+
+	p, err = newLeafPredicate("Image", "wildcard", "*.dll")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.Eval(e) {
+		t.Fatal("expected wildcard predicate not to match")
+	}
+}
+
+func TestLeafCIDR(t *testing.T) {
+	e := mapEvent(map[string]interface{}{"DestIP": "10.0.0.42"})
+
+	p, err := newLeafPredicate("DestIP", "cidr", "10.0.0.0/24")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !p.Eval(e) {
+		t.Fatal("expected CIDR predicate to match")
+	}
+
+	p, err = newLeafPredicate("DestIP", "cidr", "192.168.0.0/24")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.Eval(e) {
+		t.Fatal("expected CIDR predicate not to match")
+	}
+}
+
+func TestLeafNumericComparisons(t *testing.T) {
+	e := mapEvent(map[string]interface{}{"Count": float64(10)})
+
+	cases := []struct {
+		op    string
+		value interface{}
+		want  bool
+	}{
+		{"gt", float64(5), true},
+		{"gt", float64(10), false},
+		{"gte", float64(10), true},
+		{"lt", float64(20), true},
+		{"lt", float64(10), false},
+		{"lte", float64(10), true},
+	}
+
+	for _, c := range cases {
+		p, err := newLeafPredicate("Count", c.op, c.value)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got := p.Eval(e); got != c.want {
+			t.Errorf("op %s: got %v, want %v", c.op, got, c.want)
+		}
+	}
+}
+
+func TestLeafMissingField(t *testing.T) {
+	e := mapEvent(map[string]interface{}{"EventID": float64(1)})
+
+	p, err := newLeafPredicate("DoesNotExist", "eq", "x")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.Eval(e) {
+		t.Fatal("expected predicate on a missing field to never match")
+	}
+}
+
+func TestNestedField(t *testing.T) {
+	e := mapEvent(map[string]interface{}{
+		"EventData": map[string]interface{}{"Image": "cmd.exe"},
+	})
+
+	p, err := newLeafPredicate("EventData.Image", "eq", "cmd.exe")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !p.Eval(e) {
+		t.Fatal("expected nested field predicate to match")
+	}
+}
+
+func TestBooleanComposition(t *testing.T) {
+	e := mapEvent(map[string]interface{}{
+		"EventID": float64(4688),
+		"User":    "alice",
+	})
+
+	def := predicateDef{
+		And: []predicateDef{
+			{Field: "EventID", Op: "eq", Value: float64(4688)},
+			{Not: &predicateDef{Field: "User", Op: "eq", Value: "SYSTEM"}},
+			{Or: []predicateDef{
+				{Field: "User", Op: "eq", Value: "bob"},
+				{Field: "User", Op: "eq", Value: "alice"},
+			}},
+		},
+	}
+
+	p, err := def.build()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !p.Eval(e) {
+		t.Fatal("expected composed predicate to match")
+	}
+
+	e2 := mapEvent(map[string]interface{}{"EventID": float64(4688), "User": "SYSTEM"})
+	if p.Eval(e2) {
+		t.Fatal("expected composed predicate not to match SYSTEM user")
+	}
+}
+
+func TestEngineMatch(t *testing.T) {
+	rule := &Rule{
+		Name: "susp-cmd",
+		Tags: []string{"execution"},
+		Predicate: predicateDef{
+			Field: "Image",
+			Op:    "wildcard",
+			Value: "*\\cmd.exe",
+		},
+	}
+	if err := rule.compile(); err != nil {
+		t.Fatalf("unexpected error compiling rule: %s", err)
+	}
+
+	en := NewEngine()
+	en.Rules = append(en.Rules, rule)
+
+	e := mapEvent(map[string]interface{}{"Image": `C:\cmd.exe`})
+	matches := en.Match(e)
+	if len(matches) != 1 || matches[0].Name != "susp-cmd" {
+		t.Fatalf("expected one match for susp-cmd, got %v", matches)
+	}
+
+	e2 := mapEvent(map[string]interface{}{"Image": `C:\notepad.exe`})
+	if matches := en.Match(e2); len(matches) != 0 {
+		t.Fatalf("expected no match, got %v", matches)
+	}
+}
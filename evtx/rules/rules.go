@@ -0,0 +1,119 @@
+/*
+Package rules implements a small Sigma-style detection engine on top of
+GoEvtxMap events: rules are loaded from YAML or JSON files and describe
+boolean combinations of predicates over event fields. Matching events can
+be tagged, and the engine can be used either as a filter (only matches are
+kept) or as an annotator (a Matches array is added to every event that
+matched at least one rule).
+
+Copyright (C) 2017  RawSec SARL (0xrawsec)
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+	"gopkg.in/yaml.v2"
+)
+
+// Match is the result of a Rule matching a given event
+type Match struct {
+	Name string   `json:"name" yaml:"name"`
+	Tags []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// Rule is a single named detection rule: a boolean predicate tree matched
+// against a GoEvtxMap
+type Rule struct {
+	Name      string       `yaml:"name" json:"name"`
+	Tags      []string     `yaml:"tags" json:"tags"`
+	Predicate predicateDef `yaml:"condition" json:"condition"`
+
+	predicate Predicate
+}
+
+// compile builds the in-memory Predicate tree out of the raw definition
+// found in the rule file
+func (r *Rule) compile() (err error) {
+	r.predicate, err = r.Predicate.build()
+	return
+}
+
+// Match returns true if the rule matches the event
+func (r *Rule) Match(e *evtx.GoEvtxMap) bool {
+	if r.predicate == nil {
+		return false
+	}
+	return r.predicate.Eval(e)
+}
+
+// Engine holds a compiled set of rules and matches events against all of
+// them
+type Engine struct {
+	Rules []*Rule
+}
+
+// NewEngine creates an empty rule engine
+func NewEngine() *Engine {
+	return &Engine{Rules: make([]*Rule, 0)}
+}
+
+// LoadFile parses a YAML or JSON rule file (format guessed from the
+// extension, YAML is assumed otherwise) and adds every rule found to the
+// engine
+func (en *Engine) LoadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var raw []*Rule
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	default:
+		err = yaml.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse rule file %s: %s", path, err)
+	}
+
+	for _, r := range raw {
+		if err := r.compile(); err != nil {
+			return fmt.Errorf("rule %q: %s", r.Name, err)
+		}
+		en.Rules = append(en.Rules, r)
+	}
+	return nil
+}
+
+// Match evaluates every rule against the event and returns the list of
+// rules it matched
+func (en *Engine) Match(e *evtx.GoEvtxMap) []Match {
+	matches := make([]Match, 0)
+	for _, r := range en.Rules {
+		if r.Match(e) {
+			matches = append(matches, Match{Name: r.Name, Tags: r.Tags})
+		}
+	}
+	return matches
+}
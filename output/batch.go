@@ -0,0 +1,149 @@
+/*
+Copyright (C) 2017  RawSec SARL (0xrawsec)
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package output
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+	"github.com/0xrawsec/golang-evtx/telemetry"
+	"github.com/0xrawsec/golang-utils/log"
+)
+
+// Batcher sits in front of an Output, accumulating events into batches
+// (flushed on size or time threshold) and retrying failed writes with an
+// exponential backoff. Its input queue is bounded: once full, Add blocks,
+// so a slow or unreachable backend applies backpressure to whatever is
+// feeding it rather than silently dropping events.
+type Batcher struct {
+	name  string
+	out   Output
+	batch BatchConfig
+	retry RetryConfig
+
+	queue chan *evtx.GoEvtxMap
+	wg    sync.WaitGroup
+}
+
+// NewBatcher wraps out with the given batching and retry behaviour. name
+// is used to label the metrics emitted for this backend (e.g. "kafka").
+func NewBatcher(name string, out Output, batch BatchConfig, retry RetryConfig) *Batcher {
+	return &Batcher{
+		name:  name,
+		out:   out,
+		batch: batch,
+		retry: retry,
+		queue: make(chan *evtx.GoEvtxMap, batch.QueueSize),
+	}
+}
+
+// Open opens the underlying backend and starts the background batching
+// loop
+func (b *Batcher) Open() error {
+	if err := b.out.Open(); err != nil {
+		return err
+	}
+	b.wg.Add(1)
+	go b.run()
+	return nil
+}
+
+// Add enqueues an event to be shipped, blocking when the queue is full
+func (b *Batcher) Add(e *evtx.GoEvtxMap) {
+	b.queue <- e
+}
+
+func (b *Batcher) run() {
+	defer b.wg.Done()
+
+	interval := b.batch.MaxInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	buf := make([]*evtx.GoEvtxMap, 0, b.batch.MaxSize)
+	for {
+		select {
+		case e, ok := <-b.queue:
+			if !ok {
+				if len(buf) > 0 {
+					b.flush(buf)
+				}
+				return
+			}
+			buf = append(buf, e)
+			if len(buf) >= b.batch.MaxSize {
+				b.flush(buf)
+				buf = make([]*evtx.GoEvtxMap, 0, b.batch.MaxSize)
+			}
+		case <-ticker.C:
+			if len(buf) > 0 {
+				b.flush(buf)
+				buf = make([]*evtx.GoEvtxMap, 0, b.batch.MaxSize)
+			}
+		}
+	}
+}
+
+// flush writes a batch to the backend, retrying with exponential backoff
+// until MaxRetries is exhausted, at which point the batch is dropped and
+// logged
+func (b *Batcher) flush(batch []*evtx.GoEvtxMap) {
+	backoff := b.retry.BaseBackoff
+	if backoff <= 0 {
+		backoff = 500 * time.Millisecond
+	}
+
+	var err error
+	for attempt := 0; attempt <= b.retry.MaxRetries; attempt++ {
+		_, span := telemetry.StartSpan(context.Background(), "output.Write")
+		start := time.Now()
+		err = b.out.Write(batch)
+		telemetry.ObserveOutputWrite(b.name, start)
+		span.End()
+		if err == nil {
+			return
+		}
+		if attempt == b.retry.MaxRetries {
+			break
+		}
+		telemetry.OutputRetries.WithLabelValues(b.name).Inc()
+		log.Errorf("output write failed (attempt %d/%d): %s", attempt+1, b.retry.MaxRetries, err)
+		time.Sleep(backoff)
+		backoff *= 2
+		if b.retry.MaxBackoff > 0 && backoff > b.retry.MaxBackoff {
+			backoff = b.retry.MaxBackoff
+		}
+	}
+	telemetry.DroppedEvents.WithLabelValues(b.name).Add(float64(len(batch)))
+	log.Errorf("dropping batch of %d events after %d retries: %s", len(batch), b.retry.MaxRetries, err)
+}
+
+// Close drains any buffered events, flushes and closes the backend
+func (b *Batcher) Close() error {
+	close(b.queue)
+	b.wg.Wait()
+	if err := b.out.Flush(); err != nil {
+		return err
+	}
+	return b.out.Close()
+}
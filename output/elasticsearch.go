@@ -0,0 +1,106 @@
+/*
+Copyright (C) 2017  RawSec SARL (0xrawsec)
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+)
+
+// ElasticsearchConfig configures an Elasticsearch Output
+type ElasticsearchConfig struct {
+	URL      string
+	Index    string
+	Username string
+	Password string
+	Timeout  time.Duration
+}
+
+// Elasticsearch is an Output shipping events to an Elasticsearch cluster
+// through its bulk API
+type Elasticsearch struct {
+	Config ElasticsearchConfig
+
+	client *http.Client
+}
+
+// NewElasticsearch builds an Elasticsearch Output from its configuration
+func NewElasticsearch(cfg ElasticsearchConfig) *Elasticsearch {
+	return &Elasticsearch{Config: cfg}
+}
+
+// Open initializes the underlying HTTP client
+func (es *Elasticsearch) Open() error {
+	timeout := es.Config.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	es.client = &http.Client{Timeout: timeout}
+	return nil
+}
+
+// Write sends the batch to the _bulk endpoint, one index action per event
+func (es *Elasticsearch) Write(batch []*evtx.GoEvtxMap) error {
+	var buf bytes.Buffer
+	for _, e := range batch {
+		action := map[string]interface{}{"index": map[string]string{"_index": es.Config.Index}}
+		actionLine, err := json.Marshal(action)
+		if err != nil {
+			return err
+		}
+		docLine, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf.Write(actionLine)
+		buf.WriteByte('\n')
+		buf.Write(docLine)
+		buf.WriteByte('\n')
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimRight(es.Config.URL, "/")+"/_bulk", &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if es.Config.Username != "" {
+		req.SetBasicAuth(es.Config.Username, es.Config.Password)
+	}
+
+	resp, err := es.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("elasticsearch output: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Flush is a no-op, every Write call is synchronous
+func (es *Elasticsearch) Flush() error { return nil }
+
+// Close is a no-op, net/http clients don't need explicit closing
+func (es *Elasticsearch) Close() error { return nil }
@@ -0,0 +1,128 @@
+/*
+Copyright (C) 2017  RawSec SARL (0xrawsec)
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+)
+
+// FileConfig configures a File Output
+type FileConfig struct {
+	// Path is the base path events are written to, e.g. /var/log/evtx.json
+	// Rotated files are renamed Path.<timestamp>
+	Path string
+	// MaxSizeBytes rotates the current file once it grows past this size,
+	// 0 disables size-based rotation
+	MaxSizeBytes int64
+}
+
+// File is an Output appending newline-delimited JSON events to a local
+// file, rotating it once it exceeds Config.MaxSizeBytes
+type File struct {
+	Config FileConfig
+
+	f    *os.File
+	w    *bufio.Writer
+	size int64
+}
+
+// NewFile builds a File Output from its configuration
+func NewFile(cfg FileConfig) *File {
+	return &File{Config: cfg}
+}
+
+// Open opens (creating if needed) the destination file for appending
+func (fo *File) Open() error {
+	return fo.openCurrent()
+}
+
+func (fo *File) openCurrent() error {
+	f, err := os.OpenFile(fo.Config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	fo.f = f
+	fo.w = bufio.NewWriter(f)
+	fo.size = info.Size()
+	return nil
+}
+
+// rotate closes the current file, renames it aside and opens a new one in
+// its place
+func (fo *File) rotate() error {
+	if err := fo.w.Flush(); err != nil {
+		return err
+	}
+	if err := fo.f.Close(); err != nil {
+		return err
+	}
+	rotated := fmt.Sprintf("%s.%d", fo.Config.Path, time.Now().UnixNano())
+	if err := os.Rename(fo.Config.Path, rotated); err != nil {
+		return err
+	}
+	return fo.openCurrent()
+}
+
+// Write appends the batch as newline-delimited JSON, rotating first if
+// MaxSizeBytes would be exceeded
+func (fo *File) Write(batch []*evtx.GoEvtxMap) error {
+	for _, e := range batch {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+
+		if fo.Config.MaxSizeBytes > 0 && fo.size+int64(len(data)) > fo.Config.MaxSizeBytes {
+			if err := fo.rotate(); err != nil {
+				return err
+			}
+		}
+
+		n, err := fo.w.Write(data)
+		if err != nil {
+			return err
+		}
+		fo.size += int64(n)
+	}
+	return nil
+}
+
+// Flush flushes the buffered writer to disk
+func (fo *File) Flush() error {
+	return fo.w.Flush()
+}
+
+// Close flushes and closes the destination file
+func (fo *File) Close() error {
+	if err := fo.w.Flush(); err != nil {
+		return err
+	}
+	return fo.f.Close()
+}
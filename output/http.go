@@ -0,0 +1,90 @@
+/*
+Copyright (C) 2017  RawSec SARL (0xrawsec)
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+)
+
+// HTTPConfig configures an HttpJSON Output
+type HTTPConfig struct {
+	URL     string
+	Tag     string
+	Timeout time.Duration
+}
+
+// HttpJSON is an Output posting newline-delimited JSON batches to an HTTP
+// endpoint
+type HttpJSON struct {
+	Config HTTPConfig
+
+	client *http.Client
+}
+
+// NewHttpJSON builds an HttpJSON Output from its configuration
+func NewHttpJSON(cfg HTTPConfig) *HttpJSON {
+	return &HttpJSON{Config: cfg}
+}
+
+// Open initializes the underlying HTTP client
+func (h *HttpJSON) Open() error {
+	timeout := h.Config.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	h.client = &http.Client{Timeout: timeout}
+	return nil
+}
+
+// Write POSTs the batch as newline-delimited JSON
+func (h *HttpJSON) Write(batch []*evtx.GoEvtxMap) error {
+	var buf bytes.Buffer
+	for _, e := range batch {
+		if h.Config.Tag != "" {
+			(*e)["Tag"] = h.Config.Tag
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	resp, err := h.client.Post(h.Config.URL, "application/x-ndjson", &buf)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("http output: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Flush is a no-op, every Write call is synchronous
+func (h *HttpJSON) Flush() error { return nil }
+
+// Close is a no-op, net/http clients don't need explicit closing
+func (h *HttpJSON) Close() error { return nil }
@@ -0,0 +1,221 @@
+/*
+Copyright (C) 2017  RawSec SARL (0xrawsec)
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package output
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+
+	"github.com/Shopify/sarama"
+)
+
+// KafkaConfig configures a Kafka producer Output
+type KafkaConfig struct {
+	BrokerURLs string
+	Topic      string
+	ClientID   string
+	Tag        string
+}
+
+// Kafka is an Output producing events to a Kafka topic
+type Kafka struct {
+	Config KafkaConfig
+
+	producer sarama.SyncProducer
+}
+
+// NewKafka builds a Kafka Output from its configuration
+func NewKafka(cfg KafkaConfig) *Kafka {
+	return &Kafka{Config: cfg}
+}
+
+// Open connects the producer to the configured brokers
+func (k *Kafka) Open() error {
+	cfg := sarama.NewConfig()
+	cfg.ClientID = k.Config.ClientID
+	cfg.Producer.Return.Successes = true
+
+	producer, err := sarama.NewSyncProducer(strings.Split(k.Config.BrokerURLs, ","), cfg)
+	if err != nil {
+		return err
+	}
+	k.producer = producer
+	return nil
+}
+
+// Write ships a batch of events to the configured Kafka topic
+func (k *Kafka) Write(batch []*evtx.GoEvtxMap) error {
+	msgs := make([]*sarama.ProducerMessage, 0, len(batch))
+	for _, e := range batch {
+		if k.Config.Tag != "" {
+			(*e)["Tag"] = k.Config.Tag
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, &sarama.ProducerMessage{Topic: k.Config.Topic, Value: sarama.ByteEncoder(data)})
+	}
+	return k.producer.SendMessages(msgs)
+}
+
+// Flush is a no-op: the sync producer has already waited for acks by the
+// time Write returns
+func (k *Kafka) Flush() error { return nil }
+
+// Close shuts the underlying producer down
+func (k *Kafka) Close() error {
+	if k.producer != nil {
+		return k.producer.Close()
+	}
+	return nil
+}
+
+// TLSConfig holds TLS settings for a KafkaConsumer. KafkaConsumer.Open only
+// applies Enable and InsecureSkipVerify; CAFile, CertFile and KeyFile are
+// not read.
+type TLSConfig struct {
+	Enable             bool
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+// SASLConfig holds SASL/PLAIN credentials for a KafkaConsumer
+type SASLConfig struct {
+	Enable   bool
+	User     string
+	Password string
+}
+
+// KafkaConsumer subscribes to a set of Kafka topics and decodes
+// JSON-encoded GoEvtxMap events off them, so evtxdump can run as a
+// downstream filter/enricher fed by a Kafka producer (e.g. another
+// evtxdump instance shipping parsed EVTX files).
+type KafkaConsumer struct {
+	BrokerURLs string
+	GroupID    string
+	Topics     []string
+	// Offset is either "oldest" or "newest"
+	Offset string
+	TLS    TLSConfig
+	SASL   SASLConfig
+
+	consumer sarama.ConsumerGroup
+	cancel   chan struct{}
+}
+
+// Open connects the consumer group to the brokers
+func (kc *KafkaConsumer) Open() error {
+	cfg := sarama.NewConfig()
+	cfg.Consumer.Return.Errors = true
+
+	switch strings.ToLower(kc.Offset) {
+	case "newest":
+		cfg.Consumer.Offsets.Initial = sarama.OffsetNewest
+	default:
+		cfg.Consumer.Offsets.Initial = sarama.OffsetOldest
+	}
+
+	if kc.TLS.Enable {
+		cfg.Net.TLS.Enable = true
+		cfg.Net.TLS.Config = &tls.Config{InsecureSkipVerify: kc.TLS.InsecureSkipVerify}
+	}
+
+	if kc.SASL.Enable {
+		cfg.Net.SASL.Enable = true
+		cfg.Net.SASL.User = kc.SASL.User
+		cfg.Net.SASL.Password = kc.SASL.Password
+	}
+
+	group, err := sarama.NewConsumerGroup(strings.Split(kc.BrokerURLs, ","), kc.GroupID, cfg)
+	if err != nil {
+		return err
+	}
+	kc.consumer = group
+	kc.cancel = make(chan struct{})
+	return nil
+}
+
+// Events returns a channel of events decoded from the subscribed topics.
+// It runs the consume loop in the background until Close is called.
+func (kc *KafkaConsumer) Events() (chan *evtx.GoEvtxMap, chan error) {
+	out := make(chan *evtx.GoEvtxMap, 4096)
+	errc := make(chan error, 1)
+
+	handler := &consumerHandler{out: out}
+
+	ctx, stop := context.WithCancel(context.Background())
+	go func() {
+		<-kc.cancel
+		stop()
+	}()
+
+	go func() {
+		defer close(out)
+		defer close(errc)
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := kc.consumer.Consume(ctx, kc.Topics, handler); err != nil {
+				errc <- fmt.Errorf("kafka consume error: %s", err)
+				return
+			}
+		}
+	}()
+
+	return out, errc
+}
+
+// Close stops consuming and releases the consumer group
+func (kc *KafkaConsumer) Close() error {
+	if kc.cancel != nil {
+		close(kc.cancel)
+	}
+	if kc.consumer != nil {
+		return kc.consumer.Close()
+	}
+	return nil
+}
+
+// consumerHandler implements sarama.ConsumerGroupHandler, decoding each
+// message as a JSON GoEvtxMap
+type consumerHandler struct {
+	out chan *evtx.GoEvtxMap
+}
+
+func (h *consumerHandler) Setup(sarama.ConsumerGroupSession) error   { return nil }
+func (h *consumerHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+func (h *consumerHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		e := make(evtx.GoEvtxMap)
+		if err := json.Unmarshal(msg.Value, &e); err == nil {
+			h.out <- &e
+		}
+		sess.MarkMessage(msg, "")
+	}
+	return nil
+}
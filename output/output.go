@@ -0,0 +1,75 @@
+/*
+Package output groups the destinations evtxdump can ship parsed EVTX
+events to (HTTP, TCP, Kafka, Elasticsearch, Splunk, syslog, local files...).
+
+Copyright (C) 2017  RawSec SARL (0xrawsec)
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package output
+
+import (
+	"time"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+)
+
+// Output is the interface implemented by every destination evtxdump can
+// send parsed events to. Backends are expected to be driven through a
+// Batcher rather than called directly, so Write always receives a batch,
+// never a single event.
+type Output interface {
+	// Open connects/initializes the backend using whatever configuration
+	// it was constructed with
+	Open() error
+	// Write ships a batch of events to the backend
+	Write(batch []*evtx.GoEvtxMap) error
+	// Flush makes sure everything handed to Write so far has actually
+	// left the process (e.g. flush a buffered writer or wait for acks)
+	Flush() error
+	// Close releases any resource held by the backend
+	Close() error
+}
+
+// RetryConfig controls the retry-with-backoff behaviour a Batcher applies
+// around every Output.Write call
+type RetryConfig struct {
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// DefaultRetryConfig is used by evtxdump when the legacy flags are used
+// without further tuning
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{MaxRetries: 5, BaseBackoff: 500 * time.Millisecond, MaxBackoff: 30 * time.Second}
+}
+
+// BatchConfig controls how many events a Batcher accumulates before
+// flushing, and how long it holds on to a partial batch
+type BatchConfig struct {
+	MaxSize     int
+	MaxInterval time.Duration
+	// QueueSize bounds the number of events a Batcher buffers in front of
+	// the backend; once full, Add blocks, applying backpressure to the
+	// EVTX reader instead of dropping events
+	QueueSize int
+}
+
+// DefaultBatchConfig is used by evtxdump when the legacy flags are used
+// without further tuning
+func DefaultBatchConfig() BatchConfig {
+	return BatchConfig{MaxSize: 200, MaxInterval: 2 * time.Second, QueueSize: 4096}
+}
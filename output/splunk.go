@@ -0,0 +1,116 @@
+/*
+Copyright (C) 2017  RawSec SARL (0xrawsec)
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package output
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+)
+
+// SplunkConfig configures a Splunk Output talking to the HTTP Event
+// Collector (HEC)
+type SplunkConfig struct {
+	URL         string
+	Token       string
+	Source      string
+	SourceType  string
+	Index       string
+	InsecureTLS bool
+	Timeout     time.Duration
+}
+
+// Splunk is an Output shipping events to a Splunk HTTP Event Collector
+type Splunk struct {
+	Config SplunkConfig
+
+	client *http.Client
+}
+
+// NewSplunk builds a Splunk Output from its configuration
+func NewSplunk(cfg SplunkConfig) *Splunk {
+	return &Splunk{Config: cfg}
+}
+
+// Open initializes the underlying HTTP client
+func (sp *Splunk) Open() error {
+	timeout := sp.Config.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	sp.client = &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: sp.Config.InsecureTLS}},
+	}
+	return nil
+}
+
+// hecEvent wraps a GoEvtxMap in the envelope expected by the HEC /event
+// endpoint
+type hecEvent struct {
+	Event      *evtx.GoEvtxMap `json:"event"`
+	Source     string          `json:"source,omitempty"`
+	SourceType string          `json:"sourcetype,omitempty"`
+	Index      string          `json:"index,omitempty"`
+}
+
+// Write posts every event of the batch to the HEC /event endpoint,
+// concatenated as Splunk expects for batched HEC requests
+func (sp *Splunk) Write(batch []*evtx.GoEvtxMap) error {
+	var buf bytes.Buffer
+	for _, e := range batch {
+		data, err := json.Marshal(hecEvent{
+			Event:      e,
+			Source:     sp.Config.Source,
+			SourceType: sp.Config.SourceType,
+			Index:      sp.Config.Index,
+		})
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	}
+
+	req, err := http.NewRequest("POST", sp.Config.URL, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Splunk "+sp.Config.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := sp.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("splunk output: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Flush is a no-op, every Write call is synchronous
+func (sp *Splunk) Flush() error { return nil }
+
+// Close is a no-op, net/http clients don't need explicit closing
+func (sp *Splunk) Close() error { return nil }
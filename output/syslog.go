@@ -0,0 +1,134 @@
+/*
+Copyright (C) 2017  RawSec SARL (0xrawsec)
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package output
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+)
+
+// SyslogConfig configures a Syslog Output
+type SyslogConfig struct {
+	// Network is "udp", "tcp" or "tls"
+	Network  string
+	Addr     string
+	Facility int
+	Severity int
+	Tag      string
+	// InsecureTLS skips certificate verification when Network is "tls"
+	InsecureTLS bool
+}
+
+// Syslog is an Output forwarding events as RFC 5424 messages over UDP, TCP
+// or TLS, the JSON-encoded event being the message body (no structured data)
+type Syslog struct {
+	Config SyslogConfig
+
+	conn net.Conn
+}
+
+// NewSyslog builds a Syslog Output from its configuration
+func NewSyslog(cfg SyslogConfig) *Syslog {
+	return &Syslog{Config: cfg}
+}
+
+// dial connects to the configured syslog receiver over whichever network
+// Config.Network names
+func (sl *Syslog) dial() (net.Conn, error) {
+	switch sl.Config.Network {
+	case "tls":
+		return tls.Dial("tcp", sl.Config.Addr, &tls.Config{InsecureSkipVerify: sl.Config.InsecureTLS})
+	case "tcp":
+		return net.Dial("tcp", sl.Config.Addr)
+	default:
+		return net.Dial("udp", sl.Config.Addr)
+	}
+}
+
+// Open dials the configured syslog receiver
+func (sl *Syslog) Open() error {
+	conn, err := sl.dial()
+	if err != nil {
+		return err
+	}
+	sl.conn = conn
+	return nil
+}
+
+// reconnect replaces a dead connection with a fresh one, so a write that
+// fails because the peer went away can be retried once the link is back
+// instead of failing every batch for the rest of the process
+func (sl *Syslog) reconnect() error {
+	if sl.conn != nil {
+		sl.conn.Close()
+	}
+	conn, err := sl.dial()
+	if err != nil {
+		return err
+	}
+	sl.conn = conn
+	return nil
+}
+
+// Write emits one RFC 5424 message per event, with the JSON-encoded
+// GoEvtxMap as the message body, reconnecting once and retrying if the
+// connection has gone bad (UDP is connectionless and never fails this way,
+// but TCP and TLS can)
+func (sl *Syslog) Write(batch []*evtx.GoEvtxMap) error {
+	hostname, _ := os.Hostname()
+	pri := sl.Config.Facility*8 + sl.Config.Severity
+	appName := sl.Config.Tag
+	if appName == "" {
+		appName = "-"
+	}
+
+	for _, e := range batch {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		msg := []byte(fmt.Sprintf("<%d>1 %s %s %s - - - %s\n",
+			pri, time.Now().UTC().Format(time.RFC3339), hostname, appName, data))
+		if _, err := sl.conn.Write(msg); err != nil {
+			if err := sl.reconnect(); err != nil {
+				return err
+			}
+			if _, err := sl.conn.Write(msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Flush is a no-op, writes go straight to the socket
+func (sl *Syslog) Flush() error { return nil }
+
+// Close closes the underlying connection
+func (sl *Syslog) Close() error {
+	if sl.conn != nil {
+		return sl.conn.Close()
+	}
+	return nil
+}
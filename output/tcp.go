@@ -0,0 +1,104 @@
+/*
+Copyright (C) 2017  RawSec SARL (0xrawsec)
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package output
+
+import (
+	"encoding/json"
+	"net"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+)
+
+// TCPConfig configures a TcpJSON Output
+type TCPConfig struct {
+	Addr string
+	Tag  string
+}
+
+// TcpJSON is an Output writing newline-delimited JSON events to a plain
+// TCP socket
+type TcpJSON struct {
+	Config TCPConfig
+
+	conn net.Conn
+}
+
+// NewTcpJSON builds a TcpJSON Output from its configuration
+func NewTcpJSON(cfg TCPConfig) *TcpJSON {
+	return &TcpJSON{Config: cfg}
+}
+
+// Open dials the configured TCP address
+func (t *TcpJSON) Open() error {
+	conn, err := net.Dial("tcp", t.Config.Addr)
+	if err != nil {
+		return err
+	}
+	t.conn = conn
+	return nil
+}
+
+// reconnect replaces a dead connection with a fresh one, so a write that
+// fails because the peer went away can be retried once the link is back
+// instead of failing every batch for the rest of the process
+func (t *TcpJSON) reconnect() error {
+	if t.conn != nil {
+		t.conn.Close()
+	}
+	conn, err := net.Dial("tcp", t.Config.Addr)
+	if err != nil {
+		return err
+	}
+	t.conn = conn
+	return nil
+}
+
+// Write writes the batch as newline-delimited JSON to the socket,
+// reconnecting once and retrying if the connection has gone bad
+func (t *TcpJSON) Write(batch []*evtx.GoEvtxMap) error {
+	for _, e := range batch {
+		if t.Config.Tag != "" {
+			(*e)["Tag"] = t.Config.Tag
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		line := append(data, '\n')
+		if _, err := t.conn.Write(line); err != nil {
+			if err := t.reconnect(); err != nil {
+				return err
+			}
+			if _, err := t.conn.Write(line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Flush is a no-op, writes go straight to the socket
+func (t *TcpJSON) Flush() error { return nil }
+
+// Close closes the underlying connection
+func (t *TcpJSON) Close() error {
+	if t.conn != nil {
+		return t.conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+Copyright (C) 2017  RawSec SARL (0xrawsec)
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package telemetry
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/zipkin"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer is the tracer evtxdump uses once InitTracing has been called; it
+// defaults to the global no-op tracer so spans are free until tracing is
+// actually enabled
+var Tracer = otel.Tracer("evtxdump")
+
+// Config describes how to wire up exporting of traces
+type Config struct {
+	// Exporter is one of "otlp", "zipkin", "jaeger"; anything else disables
+	// tracing
+	Exporter     string
+	Endpoint     string
+	ServiceName  string
+	SamplerRatio float64
+}
+
+// InitTracing configures the global tracer provider according to cfg and
+// returns a shutdown func to flush and release the exporter. If cfg names
+// no known exporter, InitTracing is a no-op and the returned func does
+// nothing.
+func InitTracing(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	var exp sdktrace.SpanExporter
+	var err error
+
+	switch cfg.Exporter {
+	case "otlp":
+		exp, err = otlptracegrpc.New(ctx, otlptracegrpc.WithEndpoint(cfg.Endpoint), otlptracegrpc.WithInsecure())
+	case "zipkin":
+		exp, err = zipkin.New(cfg.Endpoint)
+	case "jaeger":
+		exp, err = jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(cfg.Endpoint)))
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("telemetry: failed to init %s exporter: %s", cfg.Exporter, err)
+	}
+
+	res, err := resource.New(ctx, resource.WithAttributes(semconv.ServiceNameKey.String(cfg.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("telemetry: failed to build resource: %s", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.TraceIDRatioBased(cfg.SamplerRatio)),
+	)
+	otel.SetTracerProvider(tp)
+	Tracer = tp.Tracer("evtxdump")
+
+	return tp.Shutdown, nil
+}
+
+// StartSpan is a thin wrapper around Tracer.Start kept here so call sites
+// in the tool don't need to import the otel trace package directly
+func StartSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return Tracer.Start(ctx, name)
+}
@@ -0,0 +1,129 @@
+/*
+Copyright (C) 2017  RawSec SARL (0xrawsec)
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+)
+
+// writeTempFile writes data to a new temp file and returns it opened for
+// reading, removing it on test cleanup
+func writeTempFile(t *testing.T, data []byte) *os.File {
+	t.Helper()
+
+	f, err := ioutil.TempFile("", "evtxdump-carving-test")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %s", err)
+	}
+	t.Cleanup(func() {
+		f.Close()
+		os.Remove(f.Name())
+	})
+
+	if _, err := f.Write(data); err != nil {
+		t.Fatalf("failed to write temp file: %s", err)
+	}
+	if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+		t.Fatalf("failed to rewind temp file: %s", err)
+	}
+	return f
+}
+
+// TestScanWindowOffsetsAcrossBoundary checks that a chunk magic straddling
+// the boundary between two ChunkSize-wide reads is still found, thanks to
+// each window overlapping the next by len(ChunkMagic) bytes
+func TestScanWindowOffsetsAcrossBoundary(t *testing.T) {
+	magicOffset := int64(evtx.ChunkSize) - 2
+	data := make([]byte, int64(evtx.ChunkSize)*2)
+	copy(data[magicOffset:], evtx.ChunkMagic)
+
+	f := writeTempFile(t, data)
+
+	offsets, err := scanWindowOffsets(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(offsets) != 1 || offsets[0] != magicOffset {
+		t.Fatalf("expected a single magic at offset %d, got %v", magicOffset, offsets)
+	}
+}
+
+// TestScanWindowOffsetsDedupesOverlap checks that a magic falling in the
+// byte range two consecutive windows both cover is only reported once
+func TestScanWindowOffsetsDedupesOverlap(t *testing.T) {
+	magicOffset := int64(evtx.ChunkSize)
+	data := make([]byte, int64(evtx.ChunkSize)*2)
+	copy(data[magicOffset:], evtx.ChunkMagic)
+
+	f := writeTempFile(t, data)
+
+	offsets, err := scanWindowOffsets(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(offsets) != 1 || offsets[0] != magicOffset {
+		t.Fatalf("expected a single magic at offset %d, got %v", magicOffset, offsets)
+	}
+}
+
+// TestValidatedChunksNearestBefore checks that recovery looks up the
+// validated chunk nearest to it by file offset (i.e. by window index),
+// regardless of which indices in between have validated
+func TestValidatedChunksNearestBefore(t *testing.T) {
+	vc := newValidatedChunks(5)
+
+	if got := vc.nearestBefore(0); got != nil {
+		t.Fatalf("expected no validated chunk before index 0, got %v", got)
+	}
+
+	vc.set(0, evtx.Chunk{Offset: 0})
+
+	for _, idx := range []int{1, 2, 3, 4} {
+		got := vc.nearestBefore(idx)
+		if got == nil || got.Offset != 0 {
+			t.Fatalf("index %d: expected to see the chunk validated at index 0, got %v", idx, got)
+		}
+	}
+
+	vc.set(2, evtx.Chunk{Offset: 2 * int64(evtx.ChunkSize)})
+
+	if got := vc.nearestBefore(3); got == nil || got.Offset != 2*int64(evtx.ChunkSize) {
+		t.Fatalf("expected index 3 to see the closer chunk validated at index 2, got %v", got)
+	}
+	if got := vc.nearestBefore(1); got == nil || got.Offset != 0 {
+		t.Fatalf("expected index 1 to still see the chunk validated at index 0, got %v", got)
+	}
+}
+
+// TestRecoverRecordsNoPreviousChunk checks that recovery is a no-op when
+// no earlier chunk has validated yet, rather than recovering against an
+// empty string/template table
+func TestRecoverRecordsNoPreviousChunk(t *testing.T) {
+	cs := &carveStats{}
+	events := recoverRecords([]byte{}, 0, nil, cs)
+	if len(events) != 0 {
+		t.Fatalf("expected no recovered events, got %d", len(events))
+	}
+	if cs.RecordsRecovered != 0 {
+		t.Fatalf("expected RecordsRecovered to stay 0, got %d", cs.RecordsRecovered)
+	}
+}
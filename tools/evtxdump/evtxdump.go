@@ -20,22 +20,30 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 package main
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"runtime/pprof"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/0xrawsec/golang-evtx/evtx"
+	"github.com/0xrawsec/golang-evtx/evtx/rules"
 	"github.com/0xrawsec/golang-evtx/output"
+	"github.com/0xrawsec/golang-evtx/telemetry"
+	"github.com/0xrawsec/golang-evtx/watch"
 	"github.com/0xrawsec/golang-utils/args"
 	"github.com/0xrawsec/golang-utils/log"
 )
@@ -73,12 +81,23 @@ var (
 	brURL         string
 	cID           string
 	topic         string
+	inputType     string
+	inGroupID     string
+	inTopics      string
+	inOffset      string
+	inTLS         bool
+	inTLSInsecure bool
+	inSASL        bool
+	inSASLUser    string
+	inSASLPass    string
 	start, stop   args.DateVar
 	chunkHeaderRE = regexp.MustCompile(evtx.ChunkMagic)
 	defaultTime   = time.Time{}
 	eventIds      map[int64]bool
 	usernames     map[string]bool
 	fields        map[string]bool
+	ruleEngine    *rules.Engine
+	ruleFilter    bool
 )
 
 //////////////////////////// stat structure ////////////////////////////////////
@@ -122,35 +141,24 @@ func (s *stats) print() {
 
 /////////////////////////////// Carving functions //////////////////////////////
 
-// Find the potential chunks
-func findChunksOffsets(r io.ReadSeeker) (co chan int64) {
-	co = make(chan int64, 42)
-	realPrevOffset, _ := r.Seek(0, os.SEEK_CUR)
-	go func() {
-		defer close(co)
-		rr := bufio.NewReader(r)
-		for loc := chunkHeaderRE.FindReaderIndex(rr); loc != nil; loc = chunkHeaderRE.FindReaderIndex(rr) {
-			realOffset, _ := r.Seek(0, os.SEEK_CUR)
-			co <- realPrevOffset + int64(loc[0])
-			realPrevOffset = realOffset - int64(rr.Buffered())
-		}
-	}()
-	return
-}
-
 // return an evtx.Chunk object from a reader
-func fetchChunkFromReader(r io.ReadSeeker, offset int64) (evtx.Chunk, error) {
+func fetchChunkFromReader(ctx context.Context, r io.ReadSeeker, offset int64) (evtx.Chunk, error) {
+	_, span := telemetry.StartSpan(ctx, "fetchChunkFromReader")
+	defer span.End()
+
 	var err error
 	c := evtx.NewChunk()
 	evtx.GoToSeeker(r, offset)
 	c.Offset = offset
 	c.Data = make([]byte, evtx.ChunkSize)
 	if _, err = r.Read(c.Data); err != nil {
+		telemetry.ParseErrors.Inc()
 		return c, err
 	}
 	reader := bytes.NewReader(c.Data)
 	c.ParseChunkHeader(reader)
 	if err = c.Header.Validate(); err != nil {
+		telemetry.ParseErrors.Inc()
 		return c, err
 	}
 	// Go to after Header
@@ -158,46 +166,314 @@ func fetchChunkFromReader(r io.ReadSeeker, offset int64) (evtx.Chunk, error) {
 	c.ParseStringTable(reader)
 	err = c.ParseTemplateTable(reader)
 	if err != nil {
+		telemetry.ParseErrors.Inc()
 		return c, err
 	}
 	err = c.ParseEventOffsets(reader)
 	if err != nil {
+		telemetry.ParseErrors.Inc()
 		return c, err
 	}
+	telemetry.ChunksParsed.Inc()
 	return c, nil
 }
 
-// main routine to carve a file
+// recordMagicRE matches the per-record signature (0x00002a2a) EVTX uses at
+// the start of every record, independently of the chunk it lives in
+var recordMagicRE = regexp.MustCompile(regexp.QuoteMeta("\x2a\x2a\x00\x00"))
+
+// carveStats collects forensic carving statistics for a single file,
+// reported in the same CSV style as -s
+type carveStats struct {
+	ChunksFound      int64
+	ChunksValidated  int64
+	RecordsRecovered int64
+	BytesSkipped     int64
+}
+
+// print reports the carving statistics for datafile in CSV format
+func (cs *carveStats) print(datafile string) {
+	fmt.Printf("File,ChunksFound,ChunksValidated,RecordsRecovered,BytesSkipped\n")
+	fmt.Printf("%s,%d,%d,%d,%d\n", datafile, cs.ChunksFound, cs.ChunksValidated, cs.RecordsRecovered, cs.BytesSkipped)
+}
+
+// scanWindowOffsets scans f in overlapping windows (window size =
+// ChunkSize + len(ChunkMagic), stepping by ChunkSize) so a chunk magic
+// straddling a window boundary is never missed, returning the distinct
+// offsets where a chunk magic was found, in ascending order
+func scanWindowOffsets(f *os.File) ([]int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	window := int64(evtx.ChunkSize) + int64(len(evtx.ChunkMagic))
+
+	seen := make(map[int64]bool)
+	offsets := make([]int64, 0)
+	buf := make([]byte, window)
+
+	for start := int64(0); start < size; start += int64(evtx.ChunkSize) {
+		n, err := f.ReadAt(buf, start)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		for _, loc := range chunkHeaderRE.FindAllIndex(buf[:n], -1) {
+			abs := start + int64(loc[0])
+			if !seen[abs] {
+				seen[abs] = true
+				offsets = append(offsets, abs)
+			}
+		}
+		if int64(n) < window {
+			break
+		}
+	}
+
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	return offsets, nil
+}
+
+// validatedChunks tracks, per window index, the chunk that validated at
+// that position (nil until it has). Because workers validate chunks out of
+// file-offset order, a plain "most recently completed" pointer would let a
+// damaged chunk borrow tables from whichever neighbour happened to finish
+// first rather than the one nearest to it in the file; indexing by window
+// position lets recovery walk backwards to the true nearest preceding
+// validated chunk instead.
+type validatedChunks struct {
+	mu     sync.RWMutex
+	chunks []*evtx.Chunk
+}
+
+func newValidatedChunks(n int) *validatedChunks {
+	return &validatedChunks{chunks: make([]*evtx.Chunk, n)}
+}
+
+func (v *validatedChunks) set(idx int, c evtx.Chunk) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.chunks[idx] = &c
+}
+
+// nearestBefore returns the validated chunk with the greatest window index
+// below idx, i.e. the nearest preceding chunk by file offset, or nil if
+// none has validated yet
+func (v *validatedChunks) nearestBefore(idx int) *evtx.Chunk {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	for i := idx - 1; i >= 0; i-- {
+		if v.chunks[i] != nil {
+			return v.chunks[i]
+		}
+	}
+	return nil
+}
+
+// recoverRecords performs best-effort recovery of individual events out of
+// a chunk whose header failed Validate(): it scans the raw chunk bytes for
+// the per-record magic and reparses forward from the earliest one that
+// yields events, using the string and template tables of the nearest
+// preceding validated chunk, since BinXML templates are chunk-local and a
+// damaged chunk carries none of its own. last is nil when no earlier chunk
+// has validated yet, in which case recovery is impossible.
+//
+// ParseEventOffsets walks every record from wherever the reader is seeked
+// through to the end of the buffer in a single pass (the same way
+// fetchChunkFromReader uses it on a whole, valid chunk), so once one of the
+// magic hits parses successfully it has already accounted for every record
+// after it; retrying from each later hit in that range would just re-walk
+// and re-emit the same records. consumed tracks how far the last
+// successful parse reached so later hits inside that range are skipped,
+// and only a hit past it (i.e. one found after a parse failure) is retried.
+func recoverRecords(raw []byte, offset int64, last *evtx.Chunk, cs *carveStats) []*evtx.GoEvtxMap {
+	events := make([]*evtx.GoEvtxMap, 0)
+	if last == nil {
+		return events
+	}
+
+	consumed := -1
+	for _, loc := range recordMagicRE.FindAllIndex(raw, -1) {
+		if loc[0] <= consumed {
+			continue
+		}
+
+		recovered := evtx.NewChunk()
+		recovered.Offset = offset
+		recovered.Data = raw
+		recovered.Header = last.Header
+		recovered.StringTable = last.StringTable
+		recovered.TemplateTable = last.TemplateTable
+
+		reader := bytes.NewReader(raw)
+		evtx.GoToSeeker(reader, int64(loc[0]))
+		if err := recovered.ParseEventOffsets(reader); err != nil {
+			continue
+		}
+
+		n := 0
+		for e := range recovered.Events() {
+			events = append(events, e)
+			atomic.AddInt64(&cs.RecordsRecovered, 1)
+			n++
+		}
+		if n > 0 {
+			consumed = len(raw)
+		}
+	}
+	return events
+}
+
+// validateWindow reads and parses the chunk found at offset. On success it
+// records it in vc (idx being this window's position in the file-offset
+// sorted list of windows being carved) and returns its events. On failure
+// it returns the raw bytes instead, so the caller can defer recovery to a
+// later phase once every window has had a chance to validate.
+func validateWindow(ctx context.Context, f *os.File, idx int, offset int64, vc *validatedChunks, cs *carveStats) (events []*evtx.GoEvtxMap, raw []byte) {
+	atomic.AddInt64(&cs.ChunksFound, 1)
+
+	raw = make([]byte, evtx.ChunkSize)
+	if _, err := f.ReadAt(raw, offset); err != nil && err != io.EOF {
+		log.Error(err)
+		return nil, nil
+	}
+
+	chunk, err := fetchChunkFromReader(ctx, bytes.NewReader(raw), 0)
+	if err != nil {
+		atomic.AddInt64(&cs.BytesSkipped, int64(evtx.ChunkSize))
+		return nil, raw
+	}
+
+	chunk.Offset = offset
+	atomic.AddInt64(&cs.ChunksValidated, 1)
+	vc.set(idx, chunk)
+
+	events = make([]*evtx.GoEvtxMap, 0)
+	_, eventsSpan := telemetry.StartSpan(ctx, "chunk.Events")
+	for e := range chunk.Events() {
+		events = append(events, e)
+	}
+	eventsSpan.End()
+	return events, nil
+}
+
+// main routine to carve a file: windows are scanned for chunk magics and
+// validated in parallel by a worker pool bounded by runtime.NumCPU()
+// (phase 1), then whatever didn't validate is recovered by a second pool
+// (phase 2), fed to a single ordered output unless -u was passed. Phase 2
+// never starts until phase 1 has fully finished for every window: recovery
+// needs the nearest preceding *validated* chunk, and workers validate
+// windows out of file-offset order, so starting recovery early would let
+// it pick whichever neighbour happened to finish first instead of the true
+// nearest one, making the recovered set depend on goroutine scheduling.
 func carveFile(datafile string, offset int64, limit int) {
-	chunkCnt := 0
+	ctx, span := telemetry.StartSpan(context.Background(), "carveFile")
+	defer span.End()
+
 	f, err := os.Open(datafile)
 	if err != nil {
 		log.Abort(ExitFail, err)
 	}
 	defer f.Close()
-	f.Seek(offset, os.SEEK_SET)
-	dup, err := os.Open(datafile)
+
+	offsets, err := scanWindowOffsets(f)
 	if err != nil {
 		log.Abort(ExitFail, err)
 	}
-	defer dup.Close()
-	dup.Seek(offset, os.SEEK_SET)
 
-	for offset := range findChunksOffsets(f) {
-		log.Infof("Parsing Chunk @ Offset: %d (0x%08[1]x)", offset)
-		chunk, err := fetchChunkFromReader(dup, offset)
-		if err != nil {
-			log.Error(err)
+	start := 0
+	for start < len(offsets) && offsets[start] < offset {
+		start++
+	}
+	offsets = offsets[start:]
+	if limit > 0 && limit < len(offsets) {
+		offsets = offsets[:limit]
+	}
+
+	cs := &carveStats{}
+	vc := newValidatedChunks(len(offsets))
+	ordered := make([][]*evtx.GoEvtxMap, len(offsets))
+	damaged := make([][]byte, len(offsets))
+	stream := make(chan []*evtx.GoEvtxMap, len(offsets))
+
+	emit := func(idx int, events []*evtx.GoEvtxMap) {
+		if unordered {
+			stream <- events
+		} else {
+			ordered[idx] = events
 		}
-		for e := range chunk.Events() {
-			printEvent(e)
+	}
+
+	numWorkers := runtime.NumCPU()
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+
+	// Phase 1: validate every window
+	jobs := make(chan int, len(offsets))
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				log.Infof("Parsing Chunk @ Offset: %d (0x%08[1]x)", offsets[idx])
+				events, raw := validateWindow(ctx, f, idx, offsets[idx], vc, cs)
+				if raw != nil {
+					damaged[idx] = raw
+					continue
+				}
+				emit(idx, events)
+			}
+		}()
+	}
+	for i := range offsets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Phase 2: recover whatever didn't validate, now that vc holds every
+	// chunk that is ever going to validate
+	recoverJobs := make(chan int, len(offsets))
+	var recoverWg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		recoverWg.Add(1)
+		go func() {
+			defer recoverWg.Done()
+			for idx := range recoverJobs {
+				emit(idx, recoverRecords(damaged[idx], offsets[idx], vc.nearestBefore(idx), cs))
+			}
+		}()
+	}
+	for idx, raw := range damaged {
+		if raw != nil {
+			recoverJobs <- idx
 		}
-		chunkCnt++
+	}
+	close(recoverJobs)
 
-		if limit > 0 && chunkCnt >= limit {
-			break
+	if unordered {
+		go func() {
+			recoverWg.Wait()
+			close(stream)
+		}()
+		for events := range stream {
+			for _, e := range events {
+				printEvent(e)
+			}
+		}
+	} else {
+		recoverWg.Wait()
+		for _, events := range ordered {
+			for _, e := range events {
+				printEvent(e)
+			}
 		}
-		log.Debug("End of the loop")
+	}
+
+	if statflag {
+		cs.print(datafile)
 	}
 }
 
@@ -223,67 +499,273 @@ func deleteUnwantedFields(m *evtx.GoEvtxMap) {
 	}
 }
 
-// small routine that prints the EVTX event
-func printEvent(e *evtx.GoEvtxMap) {
-	if e != nil {
-		if eventIds != nil && !eventIds[e.EventID()] {
-			return
+// filterAndTag applies the eventIds/usernames/time filters and the rule
+// engine to e, pruning it to the requested fields and tagging it with any
+// rule matches as a side effect. It returns false if the event should be
+// dropped. Every path an event can reach (direct printing, the output
+// backends, Kafka input, watch mode) must go through this before acting on
+// e, so the filters and rule tagging can never be bypassed.
+func filterAndTag(e *evtx.GoEvtxMap) bool {
+	if e == nil {
+		return false
+	}
+
+	if eventIds != nil && !eventIds[e.EventID()] {
+		return false
+	}
+
+	if usernames != nil && usernames[strings.ToLower(e.Username())] {
+		return false
+	}
+
+	t, err := e.GetTime(&evtx.SystemTimePath)
+
+	// If not between start and stop we do not keep
+	if time.Time(start) != defaultTime && time.Time(stop) != defaultTime {
+		if t.Before(time.Time(start)) || t.After(time.Time(stop)) {
+			return false
 		}
+	}
 
-		if usernames != nil && usernames[strings.ToLower(e.Username())] {
-			return
+	// If before start we do not keep
+	if time.Time(start) != defaultTime {
+		if t.Before(time.Time(start)) {
+			return false
 		}
+	}
 
-		t, err := e.GetTime(&evtx.SystemTimePath)
+	// If after stop we do not keep
+	if time.Time(stop) != defaultTime {
+		if t.After(time.Time(stop)) {
+			return false
+		}
+	}
 
-		// If not between start and stop we do not print
-		if time.Time(start) != defaultTime && time.Time(stop) != defaultTime {
-			if t.Before(time.Time(start)) || t.After(time.Time(stop)) {
-				return
-			}
+	if ruleEngine != nil {
+		matches := ruleEngine.Match(e)
+		if len(matches) == 0 && ruleFilter {
+			return false
+		}
+		if len(matches) > 0 {
+			(*e)["Matches"] = matches
 		}
+	}
 
-		// If before start we do not print
-		if time.Time(start) != defaultTime {
-			if t.Before(time.Time(start)) {
-				return
-			}
+	if fields != nil {
+		deleteUnwantedFields(e)
+	}
+
+	telemetry.EventsEmitted.WithLabelValues(e.Channel(), strconv.FormatInt(e.EventID(), 10)).Inc()
+
+	return true
+}
+
+// renderEvent prints an event that has already passed filterAndTag
+func renderEvent(e *evtx.GoEvtxMap) {
+	if timestamp {
+		if t, err := e.GetTime(&evtx.SystemTimePath); err == nil {
+			fmt.Printf("%d: %s\n", t.UnixNano(), string(evtx.ToJSON(e)))
+		} else {
+			log.Errorf("Event time not found: %s", string(evtx.ToJSON(e)))
 		}
+	} else {
+		fmt.Printf("%s\n", string(evtx.ToJSON(e)))
+	}
+}
+
+// small routine that filters and prints the EVTX event
+func printEvent(e *evtx.GoEvtxMap) {
+	if !filterAndTag(e) {
+		return
+	}
+	renderEvent(e)
+}
+
+// processEvent routes a parsed event through the stats/filter/rule/output
+// pipeline, shared by both file and Kafka input modes. The filter and rule
+// engine run exactly once here, so the output-backend path sees the same
+// events (tagged and pruned the same way) as direct printing does.
+func processEvent(e *evtx.GoEvtxMap, s *stats, out *output.Batcher) {
+	if statflag {
+		s.update(e.Channel(), e.EventID())
+		return
+	}
+	if !filterAndTag(e) {
+		return
+	}
+	if outType != "" {
+		out.Add(e)
+		return
+	}
+	renderEvent(e)
+}
+
+// consumeKafka subscribes to the configured topics and feeds every decoded
+// event through the same pipeline used for file inputs, returning once ctx
+// is cancelled so a signal-triggered shutdown still runs the deferred
+// output/tracer cleanup in main instead of the process being killed mid-batch
+func consumeKafka(ctx context.Context, s *stats, out *output.Batcher) {
+	consumer := &output.KafkaConsumer{
+		BrokerURLs: brURL,
+		GroupID:    inGroupID,
+		Topics:     strings.Split(inTopics, ","),
+		Offset:     inOffset,
+		TLS: output.TLSConfig{
+			Enable:             inTLS,
+			InsecureSkipVerify: inTLSInsecure,
+		},
+		SASL: output.SASLConfig{
+			Enable:   inSASL,
+			User:     inSASLUser,
+			Password: inSASLPass,
+		},
+	}
+
+	if err := consumer.Open(); err != nil {
+		log.Abort(ExitFail, err)
+	}
+	defer consumer.Close()
 
-		// If after stop we do not print
-		if time.Time(stop) != defaultTime {
-			if t.After(time.Time(stop)) {
-				return
+	events, errc := consumer.Events()
+	for events != nil || errc != nil {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			processEvent(e, s, out)
+		case err, ok := <-errc:
+			if !ok {
+				errc = nil
+				continue
 			}
+			log.Error(err)
 		}
+	}
+}
 
-		if fields != nil {
-			deleteUnwantedFields(e)
+// compileRegexps compiles a comma separated list of patterns, skipping
+// empty input
+func compileRegexps(patterns string) ([]*regexp.Regexp, error) {
+	if patterns == "" {
+		return nil, nil
+	}
+	res := make([]*regexp.Regexp, 0)
+	for _, p := range strings.Split(patterns, ",") {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
 		}
+		res = append(res, re)
+	}
+	return res, nil
+}
 
-		if timestamp {
-			if err == nil {
-				fmt.Printf("%d: %s\n", t.UnixNano(), string(evtx.ToJSON(e)))
-			} else {
-				log.Errorf("Event time not found: %s", string(evtx.ToJSON(e)))
+// watchHandler reads every new chunk appearing past fromOffset in path and
+// feeds its events through the usual pipeline, returning how far it got and
+// the record ID of the last event it emitted so the caller can checkpoint
+// both. It scans with scanWindowOffsets rather than the older
+// regex-over-a-bufio.Reader scanner, since a file being actively appended
+// to is exactly the case most likely to have a chunk magic land on a read
+// boundary.
+func watchHandler(s *stats, out *output.Batcher) watch.Handler {
+	return func(path string, fromOffset int64) (int64, uint64, error) {
+		ctx := context.Background()
+
+		f, err := os.Open(path)
+		if err != nil {
+			return fromOffset, 0, err
+		}
+		defer f.Close()
+
+		offsets, err := scanWindowOffsets(f)
+		if err != nil {
+			return fromOffset, 0, err
+		}
+
+		last := fromOffset
+		var lastRecordID uint64
+		for _, offset := range offsets {
+			if offset < fromOffset {
+				continue
 			}
-		} else {
-			fmt.Printf("%s\n", string(evtx.ToJSON(e)))
+			chunk, err := fetchChunkFromReader(ctx, f, offset)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			_, eventsSpan := telemetry.StartSpan(ctx, "chunk.Events")
+			for e := range chunk.Events() {
+				processEvent(e, s, out)
+				lastRecordID = uint64(e.EventRecordID())
+			}
+			eventsSpan.End()
+			last = offset + int64(evtx.ChunkSize)
 		}
+		return last, lastRecordID, nil
+	}
+}
+
+// watchDirectories recursively tails .evtx files under dirs, blocking until
+// ctx is cancelled, checkpointing progress so a restart doesn't re-emit
+// events
+func watchDirectories(ctx context.Context, dirs, checkpoint, include, exclude string, interval time.Duration, s *stats, out *output.Batcher) {
+	includeRE, err := compileRegexps(include)
+	if err != nil {
+		log.Abort(ExitFail, err)
+	}
+	excludeRE, err := compileRegexps(exclude)
+	if err != nil {
+		log.Abort(ExitFail, err)
+	}
+
+	w, err := watch.New(watch.Options{
+		Dirs:           strings.Split(dirs, ","),
+		Include:        includeRE,
+		Exclude:        excludeRE,
+		PollInterval:   interval,
+		CheckpointPath: checkpoint,
+	})
+	if err != nil {
+		log.Abort(ExitFail, err)
+	}
+
+	if err := w.Run(ctx, watchHandler(s, out)); err != nil && err != context.Canceled {
+		log.Abort(ExitFail, err)
 	}
 }
 
 ///////////////////////////////// Main /////////////////////////////////////////
 
 func main() {
-	var memprofile, cpuprofile, eventids, users, field string
+	// cancelled on SIGINT/SIGTERM so watch/Kafka mode returns instead of the
+	// process being killed out from under the deferred output/tracer cleanup
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var memprofile, cpuprofile, eventids, users, field, ruleFile string
+	var esURL, esIndex, esUser, esPass string
+	var splunkURL, splunkToken, splunkSource, splunkSourceType, splunkIndex string
+	var syslogNet, syslogAddr string
+	var syslogFacility, syslogSeverity int
+	var filePath string
+	var fileMaxSize int64
+	var batchSize, queueSize, retryMax int
+	var batchInterval, retryBackoff time.Duration
+	var metricsListen, tracingExporter, tracingEndpoint, serviceName string
+	var samplerRatio float64
+	var watchDirs, watchCheckpoint, watchInclude, watchExclude string
+	var watchInterval time.Duration
 	flag.BoolVar(&debug, "d", debug, "Enable debug mode")
 	flag.BoolVar(&header, "H", header, "Display file header and quit")
 	flag.BoolVar(&carve, "c", carve, "Carve events from file")
 	flag.BoolVar(&version, "V", version, "Show version and exit")
 	flag.BoolVar(&timestamp, "t", timestamp, "Prints event timestamp (as int) at the beginning of line to make sorting easier")
 	flag.BoolVar(&unordered, "u", unordered, "Does not care about ordering the events before printing (faster for large files)")
-	flag.BoolVar(&statflag, "s", statflag, "Prints stats about events in files")
+	flag.BoolVar(&statflag, "s", statflag, "Prints stats about events in files (or carving statistics when -c is set)")
 	flag.Int64Var(&offset, "o", offset, "Offset to start from (carving mode only)")
 	flag.IntVar(&limit, "l", limit, "Limit the number of chunks to parse (carving mode only)")
 	flag.Var(&start, "start", "Print logs starting from start")
@@ -292,7 +774,7 @@ func main() {
 	flag.StringVar(&memprofile, "memprofile", "", "write memory profile to this file")
 	flag.StringVar(&cpuprofile, "cpuprofile", "", "write cpu profile to this file")
 
-	flag.StringVar(&outType, "type", "", "Type of remote log collector. JSON-over-HTTP, JSON-over-TCP, Kafka")
+	flag.StringVar(&outType, "type", "", "Type of remote log collector: http, tcp, kafka, es, splunk, syslog, file")
 	flag.StringVar(&outHttp, "http", "", "url for sending output to remote site over HTTP")
 	flag.StringVar(&outTcp, "tcp", "", "tcp socket address for sending output to remote site over TCP")
 	flag.StringVar(&brURL, "brURL", "", "Kafka Broker URL")
@@ -300,10 +782,60 @@ func main() {
 	flag.StringVar(&cID, "cID", "", "Kafka client ID")
 	flag.StringVar(&tag, "tag", "", "special tag for matching purpose on remote collector")
 
+	flag.StringVar(&esURL, "es-url", "", "Elasticsearch URL, e.g. http://localhost:9200 (type=es)")
+	flag.StringVar(&esIndex, "es-index", "evtx", "Elasticsearch index to bulk insert into (type=es)")
+	flag.StringVar(&esUser, "es-user", "", "Elasticsearch basic auth username (type=es)")
+	flag.StringVar(&esPass, "es-pass", "", "Elasticsearch basic auth password (type=es)")
+
+	flag.StringVar(&splunkURL, "splunk-url", "", "Splunk HEC URL, e.g. https://splunk:8088/services/collector (type=splunk)")
+	flag.StringVar(&splunkToken, "splunk-token", "", "Splunk HEC token (type=splunk)")
+	flag.StringVar(&splunkSource, "splunk-source", "evtxdump", "Splunk event source (type=splunk)")
+	flag.StringVar(&splunkSourceType, "splunk-sourcetype", "evtx", "Splunk event sourcetype (type=splunk)")
+	flag.StringVar(&splunkIndex, "splunk-index", "", "Splunk index (type=splunk)")
+
+	flag.StringVar(&syslogNet, "syslog-net", "udp", "Syslog transport: udp, tcp or tls (type=syslog)")
+	flag.StringVar(&syslogAddr, "syslog-addr", "", "Syslog server address (type=syslog)")
+	flag.IntVar(&syslogFacility, "syslog-facility", 1, "Syslog facility (type=syslog)")
+	flag.IntVar(&syslogSeverity, "syslog-severity", 6, "Syslog severity (type=syslog)")
+
+	flag.StringVar(&filePath, "file-path", "", "Destination file events are appended to (type=file)")
+	flag.Int64Var(&fileMaxSize, "file-maxsize", 0, "Rotate the destination file past this size in bytes, 0 disables rotation (type=file)")
+
+	flag.IntVar(&batchSize, "batch-size", 200, "Number of events buffered before a batch is flushed to the output")
+	flag.DurationVar(&batchInterval, "batch-interval", 2*time.Second, "Longest time a partial batch is held before being flushed")
+	flag.IntVar(&queueSize, "queue-size", 4096, "Size of the output queue; once full, event production blocks (backpressure)")
+	flag.IntVar(&retryMax, "retry-max", 5, "Maximum number of retries on output write failure")
+	flag.DurationVar(&retryBackoff, "retry-backoff", 500*time.Millisecond, "Base backoff duration between output write retries, doubled on every attempt")
+
+	flag.StringVar(&metricsListen, "metrics-listen", "", "Address to expose /metrics and /healthz on, e.g. :9090 (disabled by default)")
+	flag.StringVar(&tracingExporter, "tracing", "", "Tracing exporter: otlp, zipkin or jaeger (disabled by default)")
+	flag.StringVar(&tracingEndpoint, "tracing-endpoint", "", "Endpoint of the tracing collector")
+	flag.StringVar(&serviceName, "service-name", "evtxdump", "Service name reported in traces")
+	flag.Float64Var(&samplerRatio, "sampler-rate", 1.0, "Fraction of traces to sample, between 0 and 1")
+
+	flag.StringVar(&inputType, "input", "file", "Input mode: file (default, reads FILES... arguments) or kafka")
+	flag.StringVar(&inGroupID, "input-group", "evtxdump", "Kafka consumer group ID (input=kafka)")
+	flag.StringVar(&inTopics, "input-topics", "", "Comma separated Kafka topics to consume (input=kafka)")
+	flag.StringVar(&inOffset, "input-offset", "oldest", "Kafka offset policy to start from: oldest or newest (input=kafka)")
+	flag.BoolVar(&inTLS, "input-tls", false, "Enable TLS for the Kafka consumer (input=kafka)")
+	flag.BoolVar(&inTLSInsecure, "input-tls-insecure", false, "Skip TLS certificate verification for the Kafka consumer (input=kafka)")
+	flag.BoolVar(&inSASL, "input-sasl", false, "Enable SASL/PLAIN authentication for the Kafka consumer (input=kafka)")
+	flag.StringVar(&inSASLUser, "input-sasl-user", "", "SASL username (input=kafka)")
+	flag.StringVar(&inSASLPass, "input-sasl-pass", "", "SASL password (input=kafka)")
+
 	flag.StringVar(&eventids, "ie", "", "Comma separated event IDs to include")
 	flag.StringVar(&users, "eu", "", "Comma separated usernames to exclude")
 	flag.StringVar(&field, "if", "", "Comma separated fields to include")
 
+	flag.StringVar(&ruleFile, "rule", "", "Rule file (YAML or JSON) used to tag/filter events, Sigma-style")
+	flag.BoolVar(&ruleFilter, "rule-filter", ruleFilter, "Only print events matching at least one rule from -rule")
+
+	flag.StringVar(&watchDirs, "watch", "", "Comma separated directories to recursively watch for growing .evtx files, instead of processing FILES...")
+	flag.StringVar(&watchCheckpoint, "watch-checkpoint", "evtxdump.checkpoint", "Path to the on-disk checkpoint store used by -watch")
+	flag.StringVar(&watchInclude, "watch-include", "", "Comma separated regexps; only files whose name matches at least one are watched")
+	flag.StringVar(&watchExclude, "watch-exclude", "", "Comma separated regexps; files whose name matches any are skipped")
+	flag.DurationVar(&watchInterval, "watch-interval", 5*time.Second, "How often -watch rescans directories and polls watched files for growth")
+
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "%s (commit: %s)\n%s\n%s\n\n", Version, CommitID, Copyright, License)
 		fmt.Fprintf(os.Stderr, "Usage of %s: %[1]s [OPTIONS] FILES...\n", filepath.Base(os.Args[0]))
@@ -351,40 +883,69 @@ func main() {
 		}()
 	}
 
+	// wire up tracing and metrics if requested
+	if tracingExporter != "" {
+		shutdown, err := telemetry.InitTracing(context.Background(), telemetry.Config{
+			Exporter:     tracingExporter,
+			Endpoint:     tracingEndpoint,
+			ServiceName:  serviceName,
+			SamplerRatio: samplerRatio,
+		})
+		if err != nil {
+			log.Abort(ExitFail, err)
+		}
+		defer shutdown(context.Background())
+	}
+
+	if metricsListen != "" {
+		go func() {
+			if err := telemetry.ListenAndServe(metricsListen); err != nil {
+				log.Errorf("metrics server stopped: %s", err)
+			}
+		}()
+	}
+
 	// init stats in case needed
 	s := newStats()
 
-	// init tcp sender if exists
-	var out output.Output
+	// init output backend if one was configured
+	var backend output.Output
 	switch outType {
 	case "http":
-		httpOut := &output.HttpJSON{
-			Url: outHttp,
-			Tag: tag,
-		}
-		if err := httpOut.Open(outHttp); err != nil {
-			log.Errorf("Can't init http conn", err)
-		}
-		out = httpOut
+		backend = output.NewHttpJSON(output.HTTPConfig{URL: outHttp, Tag: tag})
 	case "tcp":
-		tcpOut := &output.TcpJSON{
-			Tag: tag,
-		}
-		if err := tcpOut.Open(outTcp); err != nil {
-			log.Errorf("Can't init tcp conn", err)
-		}
-		out = tcpOut
+		backend = output.NewTcpJSON(output.TCPConfig{Addr: outTcp, Tag: tag})
 	case "kafka":
-		kafkaOut := &output.Kafka{
-			BrokerURLs: brURL,
-			Topic:      topic,
-			ClientID:   cID,
-			Tag:        tag,
-		}
-		if err := kafkaOut.Open(outHttp); err != nil {
-			log.Errorf("Can't init Kafka conn", err)
+		backend = output.NewKafka(output.KafkaConfig{BrokerURLs: brURL, Topic: topic, ClientID: cID, Tag: tag})
+	case "es":
+		backend = output.NewElasticsearch(output.ElasticsearchConfig{URL: esURL, Index: esIndex, Username: esUser, Password: esPass})
+	case "splunk":
+		backend = output.NewSplunk(output.SplunkConfig{
+			URL: splunkURL, Token: splunkToken, Source: splunkSource, SourceType: splunkSourceType, Index: splunkIndex,
+		})
+	case "syslog":
+		backend = output.NewSyslog(output.SyslogConfig{
+			Network: syslogNet, Addr: syslogAddr, Facility: syslogFacility, Severity: syslogSeverity, Tag: tag,
+		})
+	case "file":
+		backend = output.NewFile(output.FileConfig{Path: filePath, MaxSizeBytes: fileMaxSize})
+	}
+
+	var out *output.Batcher
+	if backend != nil {
+		out = output.NewBatcher(outType, backend, output.BatchConfig{
+			MaxSize:     batchSize,
+			MaxInterval: batchInterval,
+			QueueSize:   queueSize,
+		}, output.RetryConfig{
+			MaxRetries:  retryMax,
+			BaseBackoff: retryBackoff,
+			MaxBackoff:  30 * time.Second,
+		})
+		if err := out.Open(); err != nil {
+			log.Abort(ExitFail, err)
 		}
-		out = kafkaOut
+		defer out.Close()
 	}
 
 	if eventids != "" {
@@ -410,6 +971,21 @@ func main() {
 		}
 	}
 
+	if ruleFile != "" {
+		ruleEngine = rules.NewEngine()
+		if err := ruleEngine.LoadFile(ruleFile); err != nil {
+			log.Abort(ExitFail, err)
+		}
+	}
+
+	if inputType == "kafka" {
+		consumeKafka(ctx, s, out)
+	}
+
+	if watchDirs != "" {
+		watchDirectories(ctx, watchDirs, watchCheckpoint, watchInclude, watchExclude, watchInterval, s, out)
+	}
+
 	for _, evtxFile := range flag.Args() {
 		if !carve {
 			// Regular EVTX file, we use OpenDirty because
@@ -429,19 +1005,11 @@ func main() {
 				continue
 			}
 
+			_, eventsSpan := telemetry.StartSpan(context.Background(), "ef.FastEvents")
 			for e := range ef.FastEvents() {
-				if statflag {
-					// We update the stats
-					s.update(e.Channel(), e.EventID())
-				} else {
-					// We print events
-					if outType != "" {
-						out.Request(e)
-					} else {
-						printEvent(e)
-					}
-				}
+				processEvent(e, s, out)
 			}
+			eventsSpan.End()
 		} else {
 			evtx.SetModeCarving(true)
 			// We have to carve the file
@@ -0,0 +1,102 @@
+/*
+Package watch implements a recursive directory watch mode for evtxdump:
+it discovers .evtx files under one or more roots, tails them as Windows
+(or a forensic mount) keeps writing to them, and checkpoints how far into
+each file it has already read so a restart doesn't re-emit events.
+
+Copyright (C) 2017  RawSec SARL (0xrawsec)
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package watch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// Checkpoint records how far a single file has been read
+type Checkpoint struct {
+	// Inode identifies the file instance the rest of this checkpoint
+	// applies to, so a checkpoint taken against one file is never reused
+	// against a different file that was later created or rotated in under
+	// the same path
+	Inode uint64 `json:"inode"`
+	// Offset is the byte offset just past the last chunk known to have
+	// been fully processed, i.e. where reading resumes
+	Offset int64 `json:"offset"`
+	// LastRecordID is the record number of the last event read from that
+	// chunk; it is persisted but not read back when resuming, which is
+	// driven by Offset alone
+	LastRecordID uint64 `json:"last_record_id"`
+}
+
+// Store persists per-file Checkpoints to a single JSON file on disk, keyed
+// by the absolute path of the watched file
+type Store struct {
+	path string
+
+	mu   sync.Mutex
+	data map[string]Checkpoint
+}
+
+// OpenStore loads the checkpoint store from path, creating an empty one if
+// the file does not exist yet
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, data: make(map[string]Checkpoint)}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.data); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get returns the checkpoint known for path, the zero value if none
+func (s *Store) Get(path string) Checkpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data[path]
+}
+
+// Set records a new checkpoint for path and persists the whole store
+func (s *Store) Set(path string, cp Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[path] = cp
+	return s.save()
+}
+
+// save writes the store to a temporary file and renames it over the
+// destination, so a crash mid-write never corrupts the on-disk store
+func (s *Store) save() error {
+	data, err := json.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
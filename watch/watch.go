@@ -0,0 +1,164 @@
+/*
+Copyright (C) 2017  RawSec SARL (0xrawsec)
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"syscall"
+	"time"
+
+	"github.com/0xrawsec/golang-utils/log"
+)
+
+// Handler is called by the Watcher every time a file has grown: it must
+// read whatever is new past fromOffset and return how far it actually got
+// and the record ID of the last event it emitted (used as the next
+// checkpoint), so the watcher can persist both regardless of the handler
+// stopping early on a parse error.
+type Handler func(path string, fromOffset int64) (newOffset int64, lastRecordID uint64, err error)
+
+// Options configures a Watcher
+type Options struct {
+	// Dirs are the roots recursively scanned for .evtx files
+	Dirs []string
+	// Include, if non-empty, only watches files whose base name matches
+	// at least one of these regexps
+	Include []*regexp.Regexp
+	// Exclude skips files whose base name matches any of these regexps
+	Exclude []*regexp.Regexp
+	// PollInterval is how often directories are rescanned and known files
+	// checked for growth
+	PollInterval time.Duration
+	// CheckpointPath is where the on-disk checkpoint store lives
+	CheckpointPath string
+}
+
+// Watcher recursively tails .evtx files under Options.Dirs, calling a
+// Handler with the byte offset to resume from, and checkpointing progress
+// so a restart never re-emits already processed data
+type Watcher struct {
+	opts  Options
+	store *Store
+}
+
+// New creates a Watcher, loading (or creating) its checkpoint store
+func New(opts Options) (*Watcher, error) {
+	store, err := OpenStore(opts.CheckpointPath)
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{opts: opts, store: store}, nil
+}
+
+// Run scans Options.Dirs every PollInterval, feeding every matching file
+// through handler starting from its last checkpoint, until ctx is
+// cancelled
+func (w *Watcher) Run(ctx context.Context, handler Handler) error {
+	ticker := time.NewTicker(w.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, root := range w.opts.Dirs {
+			w.scanOnce(root, handler)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Watcher) scanOnce(root string, handler Handler) {
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			log.Error(err)
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if !w.matches(info.Name()) {
+			return nil
+		}
+		w.tail(path, info, handler)
+		return nil
+	})
+}
+
+// inode returns the inode backing info, or 0 if the platform doesn't expose
+// one through syscall.Stat_t
+func inode(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Ino
+	}
+	return 0
+}
+
+// matches applies the include/exclude patterns to a file's base name
+func (w *Watcher) matches(name string) bool {
+	for _, re := range w.opts.Exclude {
+		if re.MatchString(name) {
+			return false
+		}
+	}
+	if len(w.opts.Include) == 0 {
+		return filepath.Ext(name) == ".evtx"
+	}
+	for _, re := range w.opts.Include {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// tail calls handler with the checkpointed offset if the file grew past
+// it, and persists whatever offset (and last record ID) the handler made
+// it to. A checkpoint taken against a different inode under the same path
+// is discarded first, so a rotated or recreated file is always re-read
+// from the start instead of being compared against a stale offset that
+// belonged to the file it replaced.
+func (w *Watcher) tail(path string, info os.FileInfo, handler Handler) {
+	ino := inode(info)
+	cp := w.store.Get(path)
+	if ino != 0 && cp.Inode != 0 && cp.Inode != ino {
+		cp = Checkpoint{}
+	}
+
+	if info.Size() <= cp.Offset {
+		return
+	}
+
+	newOffset, lastRecordID, err := handler(path, cp.Offset)
+	if err != nil {
+		log.Errorf("watch: %s: %s", path, err)
+	}
+	if newOffset <= cp.Offset {
+		return
+	}
+
+	next := Checkpoint{Inode: ino, Offset: newOffset, LastRecordID: lastRecordID}
+	if err := w.store.Set(path, next); err != nil {
+		log.Errorf("watch: failed to checkpoint %s: %s", path, err)
+	}
+}